@@ -0,0 +1,113 @@
+// Package backends defines the storage abstraction WallClock is built on
+// top of, and a database/sql-style registry so concrete implementations
+// (postgres, sqlite, mysql, memory - see the sibling packages) can be
+// selected by the scheme of a DSN without tsn itself importing any of
+// their driver packages.
+//
+package backends
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Backend is the storage contract a WallClock delegates to. Every TSN
+// source - a real database or an in-memory counter - implements this.
+type Backend interface {
+	// NewTSN reserves and returns a single new TSN.
+	NewTSN(ctx context.Context) (int64, error)
+
+	// NewTSNRange reserves n contiguous TSNs in one call, returning the
+	// inclusive [start, end] bounds of the reserved range.
+	NewTSNRange(ctx context.Context, n int) (start, end int64, err error)
+
+	// Ping verifies the backend is reachable.
+	Ping(ctx context.Context) error
+
+	// Migrate brings the backend's own storage (schema, tables, ...) up
+	// to date. A backend with nothing to migrate (e.g. memory) may treat
+	// this as a no-op.
+	Migrate(ctx context.Context) error
+
+	// Close releases any resources (connections, files, ...) held by the
+	// backend.
+	Close() error
+}
+
+// Notifier is implemented by backends that can stream newly minted TSNs
+// as they're created (e.g. via LISTEN/NOTIFY). WallClock.Subscribe type
+// -asserts against this; backends that don't implement it simply don't
+// support Subscribe.
+type Notifier interface {
+	Subscribe(ctx context.Context) (<-chan int64, error)
+	CloseSubscription() error
+}
+
+// Tunable is implemented by backends whose connection pool can be tuned
+// (i.e. those backed by database/sql - postgres, sqlite, mysql, but not
+// memory). WallClock applies a Config's pool settings through this when
+// the backend supports it.
+type Tunable interface {
+	SetMaxOpenConns(n int)
+	SetMaxIdleConns(n int)
+	SetConnMaxLifetime(d time.Duration)
+}
+
+// VersionedMigrator is implemented by backends whose Migrate is actually
+// a chain of versioned steps that can be wound forward or back to an
+// exact version. WallClock.MigrateTo type-asserts against this.
+type VersionedMigrator interface {
+	MigrateTo(ctx context.Context, version int) error
+}
+
+// Factory opens a Backend for a DSN whose scheme it was Register()-ed
+// under. The full DSN (including scheme) is passed through so a factory
+// can parse whatever it needs from the rest of the URL.
+type Factory func(dsn string) (Backend, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a Backend factory available under the given URL scheme
+// (e.g. "postgres", "sqlite", "mem"). Typically called from a backend
+// package's init(). Panics on a nil factory or a duplicate scheme, same
+// as database/sql.Register.
+func Register(scheme string, factory Factory) {
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("backends: Register factory is nil")
+	}
+	if _, dup := registry[scheme]; dup {
+		panic("backends: Register called twice for scheme " + scheme)
+	}
+
+	registry[scheme] = factory
+}
+
+// Open parses dsn as a URL and dispatches to the Factory registered for
+// its scheme.
+func Open(dsn string) (Backend, error) {
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("backends: parsing dsn: %w", err)
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[u.Scheme]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("backends: unknown scheme %q", u.Scheme)
+	}
+
+	return factory(dsn)
+}
@@ -0,0 +1,110 @@
+// Package conformance is a shared test suite that every backends.Backend
+// implementation must pass: monotonicity, range reservation, and safe
+// concurrent use. Concrete backend packages call Run from their own
+// *_test.go with a constructor for a fresh Backend.
+//
+package conformance
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/rainerbrendle/TSN/backends"
+)
+
+// Run exercises newBackend() (called once per sub-test, so backends that
+// can't be safely reused don't need to be) against the common guarantees
+// every backends.Backend must uphold.
+func Run(t *testing.T, newBackend func() backends.Backend) {
+
+	t.Run("Monotonic", func(t *testing.T) { testMonotonic(t, newBackend()) })
+	t.Run("Range", func(t *testing.T) { testRange(t, newBackend()) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, newBackend()) })
+}
+
+func testMonotonic(t *testing.T, b backends.Backend) {
+
+	defer b.Close()
+	ctx := context.Background()
+
+	if err := b.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	var prev int64
+	for i := 0; i < 100; i++ {
+		tsn, err := b.NewTSN(ctx)
+		if err != nil {
+			t.Fatalf("NewTSN: %v", err)
+		}
+		if tsn <= prev {
+			t.Fatalf("not monotonic: %v after %v", tsn, prev)
+		}
+		prev = tsn
+	}
+}
+
+func testRange(t *testing.T, b backends.Backend) {
+
+	defer b.Close()
+	ctx := context.Background()
+
+	if err := b.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	const n = 10
+	start, end, err := b.NewTSNRange(ctx, n)
+	if err != nil {
+		t.Fatalf("NewTSNRange: %v", err)
+	}
+	if end-start+1 != n {
+		t.Fatalf("expected a range of %v, got [%v,%v]", n, start, end)
+	}
+
+	next, err := b.NewTSN(ctx)
+	if err != nil {
+		t.Fatalf("NewTSN: %v", err)
+	}
+	if next <= end {
+		t.Fatalf("NewTSN() %v did not advance past reserved range [%v,%v]", next, start, end)
+	}
+}
+
+func testConcurrent(t *testing.T, b backends.Backend) {
+
+	defer b.Close()
+	ctx := context.Background()
+
+	if err := b.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	const n = 50
+	seen := make(chan int64, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tsn, err := b.NewTSN(ctx)
+			if err != nil {
+				t.Errorf("NewTSN: %v", err)
+				return
+			}
+			seen <- tsn
+		}()
+	}
+	wg.Wait()
+	close(seen)
+
+	unique := map[int64]bool{}
+	for tsn := range seen {
+		if unique[tsn] {
+			t.Fatalf("duplicate TSN %v under concurrency", tsn)
+		}
+		unique[tsn] = true
+	}
+}
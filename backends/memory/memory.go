@@ -0,0 +1,60 @@
+// Package memory is a storage-free backends.Backend: a mutex-guarded
+// in-memory counter. It lets TSN allocation itself (NewTSN/NewTSNRange)
+// run without any database - see TestMemoryBackend in the tsn package -
+// and serves as the conformance baseline the other backends are checked
+// against. It does not implement backends.Notifier or
+// backends.VersionedMigrator, so Subscribe/MigrateTo are unavailable on
+// it; tests that exercise those still need a real postgres.
+//
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rainerbrendle/TSN/backends"
+)
+
+func init() {
+	backends.Register("mem", Open)
+}
+
+// Backend is the in-memory implementation of backends.Backend. Every
+// Backend gets its own counter - there is nothing shared across "mem://"
+// instances with the same name, unlike the networked backends.
+type Backend struct {
+	mu      sync.Mutex
+	counter int64
+}
+
+// Open implements backends.Factory for the "mem" scheme. The rest of the
+// DSN (host/path/query) is ignored; every Open call returns an
+// independent counter starting at 0.
+func Open(dsn string) (backends.Backend, error) {
+	return &Backend{}, nil
+}
+
+func (b *Backend) NewTSN(ctx context.Context) (int64, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.counter++
+	return b.counter, nil
+}
+
+func (b *Backend) NewTSNRange(ctx context.Context, n int) (start, end int64, err error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start = b.counter + 1
+	b.counter += int64(n)
+	end = b.counter
+
+	return start, end, nil
+}
+
+func (b *Backend) Ping(ctx context.Context) error    { return nil }
+func (b *Backend) Migrate(ctx context.Context) error { return nil }
+func (b *Backend) Close() error                      { return nil }
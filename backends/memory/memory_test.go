@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/rainerbrendle/TSN/backends"
+	"github.com/rainerbrendle/TSN/backends/conformance"
+)
+
+func TestConformance(t *testing.T) {
+
+	conformance.Run(t, func() backends.Backend {
+		b, err := Open("mem://")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		return b
+	})
+}
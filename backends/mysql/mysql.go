@@ -0,0 +1,109 @@
+// Package mysql is a backends.Backend backed by a MySQL database, using
+// the classic single-row "ticket sequence" trick: a table with a unique
+// stub column whose AUTO_INCREMENT id is bumped via REPLACE/ON DUPLICATE
+// KEY UPDATE and read back with LAST_INSERT_ID().
+//
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/rainerbrendle/TSN/backends"
+)
+
+func init() {
+	backends.Register("mysql", Open)
+}
+
+// Backend is the mysql implementation of backends.Backend.
+type Backend struct {
+	db *sql.DB
+}
+
+// Open implements backends.Factory for the "mysql" scheme. The scheme
+// prefix is stripped; the rest is passed straight through to the
+// go-sql-driver/mysql DSN parser.
+func Open(dsn string) (backends.Backend, error) {
+
+	dataSourceName := strings.TrimPrefix(dsn, "mysql://")
+
+	db, err := sql.Open("mysql", dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{db: db}, nil
+}
+
+func (b *Backend) NewTSN(ctx context.Context) (int64, error) {
+
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx,
+		"INSERT INTO clock_counter (stub) VALUES ('a') ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id+1)"); err != nil {
+		return 0, err
+	}
+
+	var tsn int64
+	err = conn.QueryRowContext(ctx, "SELECT LAST_INSERT_ID()").Scan(&tsn)
+	return tsn, err
+}
+
+func (b *Backend) NewTSNRange(ctx context.Context, n int) (start, end int64, err error) {
+
+	// LAST_INSERT_ID() is connection-scoped, so the insert and the read
+	// back must share a single connection - two calls against b.db would
+	// each borrow a (possibly different) pooled connection.
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx,
+		"INSERT INTO clock_counter (stub) VALUES ('a') ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id+?)", n); err != nil {
+		return 0, 0, err
+	}
+
+	if err = conn.QueryRowContext(ctx, "SELECT LAST_INSERT_ID()").Scan(&end); err != nil {
+		return 0, 0, err
+	}
+
+	start = end - int64(n) + 1
+	return start, end, nil
+}
+
+func (b *Backend) Ping(ctx context.Context) error {
+	return b.db.PingContext(ctx)
+}
+
+func (b *Backend) Migrate(ctx context.Context) error {
+	_, err := b.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS clock_counter (
+			id   bigint NOT NULL AUTO_INCREMENT,
+			stub char(1) NOT NULL,
+			PRIMARY KEY (id),
+			UNIQUE KEY stub (stub)
+		)
+	`)
+	return err
+}
+
+func (b *Backend) Close() error {
+	return b.db.Close()
+}
+
+// SetMaxOpenConns, SetMaxIdleConns, SetConnMaxLifetime implement
+// backends.Tunable by forwarding straight to the underlying sql.DB.
+func (b *Backend) SetMaxOpenConns(n int)              { b.db.SetMaxOpenConns(n) }
+func (b *Backend) SetMaxIdleConns(n int)              { b.db.SetMaxIdleConns(n) }
+func (b *Backend) SetConnMaxLifetime(d time.Duration) { b.db.SetConnMaxLifetime(d) }
@@ -0,0 +1,248 @@
+// Package postgres is the original tsn storage backend: a PostgreSQL
+// database with a `clock` schema holding a SEQUENCE and the
+// clock.new_tsn()/clock.new_tsn_range() functions, bootstrapped via
+// github.com/rainerbrendle/TSN/migrations.
+//
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/rainerbrendle/TSN/backends"
+	"github.com/rainerbrendle/TSN/migrations"
+)
+
+func init() {
+	backends.Register("postgres", Open)
+}
+
+// recommended by the pq.Listener docs
+const (
+	listenerMinReconnectInterval = 10 * time.Second
+	listenerMaxReconnectInterval = 1 * time.Minute
+)
+
+// resyncSentinel is sent on the Subscribe() channel whenever the listener
+// had to reconnect, meaning NOTIFYs may have been missed in between. 0 is
+// not a value new_tsn() ever produces (sequences start at 1).
+const resyncSentinel int64 = 0
+
+// Backend is the postgres implementation of backends.Backend (and
+// backends.Notifier / backends.VersionedMigrator).
+type Backend struct {
+	db  *sql.DB
+	dsn string // kept for pq.Listener, which dials its own connection
+
+	notifyChannel string
+	listenMu      sync.Mutex
+	listener      *pq.Listener
+}
+
+// Open implements backends.Factory for the "postgres" scheme. dsn is
+// passed straight through to sql.Open("postgres", dsn).
+func Open(dsn string) (backends.Backend, error) {
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{db: db, dsn: dsn}, nil
+}
+
+func (b *Backend) NewTSN(ctx context.Context) (int64, error) {
+
+	var tsn int64
+	err := b.db.QueryRowContext(ctx, "select clock.new_tsn()").Scan(&tsn)
+	return tsn, err
+}
+
+func (b *Backend) NewTSNRange(ctx context.Context, n int) (start, end int64, err error) {
+
+	err = b.db.QueryRowContext(ctx, "select clock.new_tsn_range($1)", n).Scan(&start)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return start, start + int64(n) - 1, nil
+}
+
+func (b *Backend) Ping(ctx context.Context) error {
+	return b.db.PingContext(ctx)
+}
+
+func (b *Backend) Migrate(ctx context.Context) error {
+	return migrations.New(b.db).Migrate(ctx)
+}
+
+// MigrateTo implements backends.VersionedMigrator.
+func (b *Backend) MigrateTo(ctx context.Context, version int) error {
+	return migrations.New(b.db).MigrateTo(ctx, version)
+}
+
+func (b *Backend) Close() error {
+	return b.db.Close()
+}
+
+// SetMaxOpenConns, SetMaxIdleConns, SetConnMaxLifetime implement
+// backends.Tunable by forwarding straight to the underlying sql.DB.
+func (b *Backend) SetMaxOpenConns(n int)              { b.db.SetMaxOpenConns(n) }
+func (b *Backend) SetMaxIdleConns(n int)              { b.db.SetMaxIdleConns(n) }
+func (b *Backend) SetConnMaxLifetime(d time.Duration) { b.db.SetConnMaxLifetime(d) }
+
+// SetNotifyChannel overrides the NOTIFY channel used by Subscribe(). If
+// never called it defaults to "clock_" + the current database name,
+// matching what migration 003's clock.new_tsn() broadcasts on.
+func (b *Backend) SetNotifyChannel(channel string) {
+	b.listenMu.Lock()
+	defer b.listenMu.Unlock()
+
+	b.notifyChannel = channel
+}
+
+func (b *Backend) notifyChannelName(ctx context.Context) (string, error) {
+
+	if b.notifyChannel != "" {
+		return b.notifyChannel, nil
+	}
+
+	var dbname string
+	if err := b.db.QueryRowContext(ctx, "select current_database()").Scan(&dbname); err != nil {
+		return "", err
+	}
+
+	return "clock_" + dbname, nil
+}
+
+// Subscribe implements backends.Notifier.
+func (b *Backend) Subscribe(ctx context.Context) (<-chan int64, error) {
+
+	b.listenMu.Lock()
+	defer b.listenMu.Unlock()
+
+	if b.listener != nil {
+		return nil, errors.New("postgres: already subscribed")
+	}
+
+	channel, err := b.notifyChannelName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := pq.NewListener(b.dsn, listenerMinReconnectInterval, listenerMaxReconnectInterval,
+		func(ev pq.ListenerEventType, err error) {})
+
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	b.listener = listener
+
+	out := make(chan int64, 64)
+	go pumpNotifications(ctx, listener, out)
+
+	return out, nil
+}
+
+// forward Notification payloads as int64, translating a nil Notification
+// (pq.Listener's own signal that it just reconnected) into resyncSentinel.
+// The payload is either a single TSN (from clock.new_tsn()) or a
+// "first-last" range (from clock.new_tsn_range(), migration 004) which is
+// expanded back into its individual TSNs.
+func pumpNotifications(ctx context.Context, listener *pq.Listener, out chan<- int64) {
+
+	defer close(out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+
+			if n == nil {
+				if !send(ctx, out, resyncSentinel) {
+					return
+				}
+				continue
+			}
+
+			tsns, err := parsePayload(n.Extra)
+			if err != nil {
+				continue
+			}
+
+			for _, tsn := range tsns {
+				if !send(ctx, out, tsn) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// parsePayload accepts either a bare "123" (clock.new_tsn()) or a
+// "123-456" inclusive range (clock.new_tsn_range()).
+func parsePayload(payload string) ([]int64, error) {
+
+	first, last, isRange := strings.Cut(payload, "-")
+	if !isRange {
+		tsn, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return []int64{tsn}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	tsns := make([]int64, 0, end-start+1)
+	for v := start; v <= end; v++ {
+		tsns = append(tsns, v)
+	}
+	return tsns, nil
+}
+
+func send(ctx context.Context, out chan<- int64, v int64) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// CloseSubscription implements backends.Notifier.
+func (b *Backend) CloseSubscription() error {
+
+	b.listenMu.Lock()
+	defer b.listenMu.Unlock()
+
+	if b.listener == nil {
+		return nil
+	}
+
+	err := b.listener.Close()
+	b.listener = nil
+
+	return err
+}
@@ -0,0 +1,100 @@
+// Package sqlite is a backends.Backend backed by a SQLite database file,
+// using an INTEGER PRIMARY KEY AUTOINCREMENT column as the sequence.
+//
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/rainerbrendle/TSN/backends"
+)
+
+func init() {
+	backends.Register("sqlite", Open)
+}
+
+// Backend is the sqlite implementation of backends.Backend.
+type Backend struct {
+	db *sql.DB
+}
+
+// Open implements backends.Factory for the "sqlite" scheme, e.g.
+// "sqlite:///tmp/foo.db". The path is whatever follows the scheme,
+// passed straight through to the go-sqlite3 driver.
+func Open(dsn string) (backends.Backend, error) {
+
+	path := strings.TrimPrefix(dsn, "sqlite://")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time; handing out a second
+	// pooled connection just means that connection's writes fail with
+	// "database is locked" instead of queueing behind the first.
+	db.SetMaxOpenConns(1)
+
+	return &Backend{db: db}, nil
+}
+
+func (b *Backend) NewTSN(ctx context.Context) (int64, error) {
+
+	var tsn int64
+	err := b.db.QueryRowContext(ctx, "INSERT INTO clock_counter DEFAULT VALUES RETURNING id").Scan(&tsn)
+	return tsn, err
+}
+
+func (b *Backend) NewTSNRange(ctx context.Context, n int) (start, end int64, err error) {
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < n; i++ {
+		var id int64
+		if err := tx.QueryRowContext(ctx, "INSERT INTO clock_counter DEFAULT VALUES RETURNING id").Scan(&id); err != nil {
+			return 0, 0, err
+		}
+		if i == 0 {
+			start = id
+		}
+		end = id
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return start, end, nil
+}
+
+func (b *Backend) Ping(ctx context.Context) error {
+	return b.db.PingContext(ctx)
+}
+
+func (b *Backend) Migrate(ctx context.Context) error {
+	_, err := b.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS clock_counter (
+			id INTEGER PRIMARY KEY AUTOINCREMENT
+		)
+	`)
+	return err
+}
+
+func (b *Backend) Close() error {
+	return b.db.Close()
+}
+
+// SetMaxOpenConns, SetMaxIdleConns, SetConnMaxLifetime implement
+// backends.Tunable by forwarding straight to the underlying sql.DB.
+func (b *Backend) SetMaxOpenConns(n int)              { b.db.SetMaxOpenConns(n) }
+func (b *Backend) SetMaxIdleConns(n int)              { b.db.SetMaxIdleConns(n) }
+func (b *Backend) SetConnMaxLifetime(d time.Duration) { b.db.SetConnMaxLifetime(d) }
@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rainerbrendle/TSN/backends"
+	"github.com/rainerbrendle/TSN/backends/conformance"
+)
+
+func TestConformance(t *testing.T) {
+
+	dir := t.TempDir()
+
+	conformance.Run(t, func() backends.Backend {
+		path := filepath.Join(dir, "clock.db")
+		os.Remove(path)
+
+		b, err := Open("sqlite://" + path)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		return b
+	})
+}
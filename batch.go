@@ -0,0 +1,222 @@
+// batch.go
+//
+// Client-side caching for TSN allocation.
+//
+// Fetching one TSN per round trip is fine until callers start asking for
+// thousands of them in a tight loop (see the TestNewTSN100000 test).
+// This file adds a batching fast path: NewTSN() hands out values from an
+// in-memory buffer that is refilled in bulk via the backend's
+// NewTSNRange, trading a bit of monotonicity-on-crash (unused buffered
+// values are simply lost) for far fewer round trips.
+//
+package tsn
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// defaults used until SetBatchSize / SetPrefetchThreshold are called
+const (
+	defaultBatchSize         = 100
+	defaultPrefetchThreshold = 0.25
+)
+
+// the batching state for a WallClock
+//
+// protected by mu. buffer[pos:] are the values not yet handed out.
+type batchState struct {
+	mu         sync.Mutex
+	buffer     []int64
+	pos        int
+	batchSize  int
+	prefetch   float64
+	refilling  bool
+	generation int64 // bumped by every refillBatch/discardBatch, see maybeBackgroundRefill
+}
+
+// lazily initialise the batch defaults for a wallclock
+// caller must hold wc.batch.mu
+func (wc *WallClock) ensureBatchState() {
+	if wc.batch.batchSize == 0 {
+		wc.batch.batchSize = defaultBatchSize
+	}
+	if wc.batch.prefetch == 0 {
+		wc.batch.prefetch = defaultPrefetchThreshold
+	}
+}
+
+// SetBatchSize
+//
+// Number of TSNs reserved from the backend per NewTSNRange call. Larger
+// values mean fewer round trips but more values lost if the process dies
+// with an unused buffer.
+//
+// Package Export
+func (wc *WallClock) SetBatchSize(n int) {
+
+	wc.batch.mu.Lock()
+	defer wc.batch.mu.Unlock()
+
+	wc.batch.batchSize = n
+}
+
+// SetPrefetchThreshold
+//
+// Fraction (0..1) of the batch that may remain before a background refill
+// is triggered, so NewTSN() rarely blocks on a range fetch.
+//
+// Package Export
+func (wc *WallClock) SetPrefetchThreshold(frac float64) {
+
+	wc.batch.mu.Lock()
+	defer wc.batch.mu.Unlock()
+
+	wc.batch.prefetch = frac
+}
+
+// NewTSNBatch
+//
+// Reserve n TSNs in a single round trip and return them as a contiguous
+// slice. Unlike NewTSN() this always talks to the backend - no caching.
+//
+// Package Export
+func (wc *WallClock) NewTSNBatch(n int) (tsns []int64, err error) {
+
+	defer func() {
+
+		if r := recover(); r != nil {
+			err = errors.New("error while reading TSN batch")
+		}
+
+	}()
+
+	start, _, rerr := wc.backend.NewTSNRange(context.Background(), n)
+	checkErr("NewTSNBatch", rerr)
+
+	tsns = make([]int64, n)
+	for i := 0; i < n; i++ {
+		tsns[i] = start + int64(i)
+	}
+
+	return tsns, nil
+}
+
+// discard whatever is left in the buffer, forcing a fresh range fetch on
+// next use. Called whenever the backend is known to be bad (e.g. a failed
+// range fetch) so we never hand out a value a dead connection may have
+// reserved twice after reconnect.
+func (wc *WallClock) discardBatch() {
+
+	wc.batch.mu.Lock()
+	defer wc.batch.mu.Unlock()
+
+	wc.batch.buffer = nil
+	wc.batch.pos = 0
+	wc.batch.generation++
+}
+
+// refill the buffer synchronously by reserving a new range
+// caller must hold wc.batch.mu
+func (wc *WallClock) refillBatch() error {
+
+	start, end, err := wc.backend.NewTSNRange(context.Background(), wc.batch.batchSize)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]int64, 0, end-start+1)
+	for v := start; v <= end; v++ {
+		buf = append(buf, v)
+	}
+
+	wc.batch.buffer = buf
+	wc.batch.pos = 0
+	wc.batch.generation++
+
+	return nil
+}
+
+// if the buffer has dropped below the prefetch threshold and a refill is
+// not already running, kick one off in the background
+// caller must hold wc.batch.mu
+func (wc *WallClock) maybeBackgroundRefill() {
+
+	remaining := len(wc.batch.buffer) - wc.batch.pos
+	if float64(remaining) > wc.batch.prefetch*float64(wc.batch.batchSize) {
+		return
+	}
+
+	if wc.batch.refilling {
+		return
+	}
+	wc.batch.refilling = true
+
+	// snapshot everything the goroutine needs while still holding the
+	// lock - batchSize must not be read concurrently with SetBatchSize,
+	// and generation lets us detect a synchronous refill/discard that
+	// ran while this fetch was in flight.
+	batchSize := wc.batch.batchSize
+	generation := wc.batch.generation
+
+	go func() {
+		start, end, err := wc.backend.NewTSNRange(context.Background(), batchSize)
+
+		wc.batch.mu.Lock()
+		defer wc.batch.mu.Unlock()
+
+		wc.batch.refilling = false
+
+		if err != nil {
+			checkString("background refill failed", err.Error())
+			// same as discardBatch (mu is already held here)
+			wc.batch.buffer = nil
+			wc.batch.pos = 0
+			wc.batch.generation++
+			return
+		}
+
+		if wc.batch.generation != generation {
+			// a synchronous refill or a discard (reconnect) happened
+			// while this range was in flight - wc.batch.buffer is no
+			// longer contiguous with it, so appending now would hand
+			// out TSNs out of order. Drop the reservation instead of
+			// risking a monotonicity violation.
+			return
+		}
+
+		buf := make([]int64, 0, end-start+1)
+		for v := start; v <= end; v++ {
+			buf = append(buf, v)
+		}
+
+		// keep whatever the caller hasn't drained yet, append the fresh range
+		wc.batch.buffer = append(wc.batch.buffer[wc.batch.pos:], buf...)
+		wc.batch.pos = 0
+		wc.batch.generation++
+	}()
+}
+
+// fast path for NewTSN(): take the next value from the buffer, refilling
+// (synchronously if empty, in the background if low) as needed
+func (wc *WallClock) newTSNCached() (int64, error) {
+
+	wc.batch.mu.Lock()
+	defer wc.batch.mu.Unlock()
+
+	wc.ensureBatchState()
+
+	if wc.batch.pos >= len(wc.batch.buffer) {
+		if err := wc.refillBatch(); err != nil {
+			return 0, err
+		}
+	}
+
+	tsn := wc.batch.buffer[wc.batch.pos]
+	wc.batch.pos++
+
+	wc.maybeBackgroundRefill()
+
+	return tsn, nil
+}
@@ -0,0 +1,68 @@
+//
+// Test suite for config.go
+//
+
+package tsn
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+
+	cfg := &Config{DSN: "postgres://user@host/$database$"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	bad := &Config{DSN: "://not a url"}
+	if err := bad.Validate(); err == nil {
+		t.Fatalf("expected malformed dsn to fail validation")
+	}
+
+	empty := &Config{}
+	if err := empty.Validate(); err == nil {
+		t.Fatalf("expected empty dsn to fail validation")
+	}
+}
+
+func TestConfigForClock(t *testing.T) {
+
+	cfg := &Config{
+		DSN:          "postgres://user@host/$database$",
+		MaxOpenConns: 5,
+		Clocks: map[string]*Config{
+			"special": {MaxOpenConns: 50},
+		},
+	}
+
+	effective := cfg.ForClock("rainer")
+	if effective.MaxOpenConns != 5 {
+		t.Fatalf("expected shared MaxOpenConns 5, got %v", effective.MaxOpenConns)
+	}
+
+	special := cfg.ForClock("special")
+	if special.MaxOpenConns != 50 {
+		t.Fatalf("expected overridden MaxOpenConns 50, got %v", special.MaxOpenConns)
+	}
+	if special.DSN != cfg.DSN {
+		t.Fatalf("expected unset override field to fall back to shared dsn")
+	}
+}
+
+func TestConfigPasswordFromEnv(t *testing.T) {
+
+	os.Setenv("TSN_TEST_PASSWORD", "hunter2")
+	defer os.Unsetenv("TSN_TEST_PASSWORD")
+
+	cfg := &Config{PasswordFrom: "env:TSN_TEST_PASSWORD"}
+
+	password, err := cfg.resolvePassword()
+	if err != nil {
+		t.Fatalf("resolvePassword: %v", err)
+	}
+	if password != "hunter2" {
+		t.Fatalf("expected hunter2, got %q", password)
+	}
+}
@@ -0,0 +1,49 @@
+// listen.go
+//
+// LISTEN/NOTIFY based subscription to newly minted TSNs. The actual
+// pq.Listener plumbing lives in backends/postgres (the only backend that
+// implements backends.Notifier so far); this file just type-asserts and
+// forwards.
+//
+package tsn
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rainerbrendle/TSN/backends"
+)
+
+// Subscribe
+//
+// Returns a channel of newly minted TSNs, backed by the wallclock's
+// backend if it implements backends.Notifier (currently: postgres, via
+// LISTEN/NOTIFY - see migration 003 in github.com/rainerbrendle/TSN/migrations,
+// which wires clock.new_tsn() up to pg_notify). Returns an error for
+// backends that don't support it.
+//
+// Package Export
+func (wc *WallClock) Subscribe(ctx context.Context) (<-chan int64, error) {
+
+	n, ok := wc.backend.(backends.Notifier)
+	if !ok {
+		return nil, errors.New("wallclock: backend does not support Subscribe")
+	}
+
+	return n.Subscribe(ctx)
+}
+
+// Close stops this wallclock's subscription (if any, see Subscribe) and
+// closes its backend.
+//
+// Package Export
+func (wc *WallClock) Close() error {
+
+	if n, ok := wc.backend.(backends.Notifier); ok {
+		if err := n.CloseSubscription(); err != nil {
+			return err
+		}
+	}
+
+	return wc.backend.Close()
+}
@@ -0,0 +1,40 @@
+//
+// Demonstrates that basic TSN allocation runs without any database, via
+// the "mem" backend (see backends/memory). Subscribe/MigrateTo remain
+// postgres-only, see listen_test.go/migrate_test.go.
+//
+
+package tsn
+
+import (
+	"fmt"
+	"testing"
+
+	_ "github.com/rainerbrendle/TSN/backends/memory"
+)
+
+func TestMemoryBackend(t *testing.T) {
+
+	cfg := &Config{DSN: "mem://$database$"}
+
+	wc, err := GetWallClockWithConfig("memory-test", cfg)
+	if err != nil {
+		fmt.Printf("PANIC %#v\n", err)
+		t.FailNow()
+	}
+
+	var prev int64
+	for i := 0; i < 1000; i++ {
+		tsn, err := wc.NewTSN()
+		if err != nil {
+			fmt.Printf("PANIC %#v\n", err)
+			t.FailNow()
+		}
+		if tsn <= prev {
+			t.Fatalf("not monotonic: %v after %v", tsn, prev)
+		}
+		prev = tsn
+	}
+
+	fmt.Printf("1000 x NEW TSN (mem) %v\n", prev)
+}
@@ -0,0 +1,37 @@
+// migrate.go
+//
+// Schema bootstrapping, delegated to whatever the wallclock's backend
+// implements. The postgres backend (backends/postgres) is the only one
+// with a real versioned migration chain right now, see
+// github.com/rainerbrendle/TSN/migrations for the files and runner.
+//
+package tsn
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rainerbrendle/TSN/backends"
+)
+
+// Migrate brings this wallclock's backend storage up to date.
+//
+// Package Export
+func (wc *WallClock) Migrate(ctx context.Context) error {
+	return wc.backend.Migrate(ctx)
+}
+
+// MigrateTo brings this wallclock to exactly the given migration version,
+// applying or reverting migrations as needed. Only supported by backends
+// that implement backends.VersionedMigrator (currently just postgres).
+//
+// Package Export
+func (wc *WallClock) MigrateTo(ctx context.Context, version int) error {
+
+	vm, ok := wc.backend.(backends.VersionedMigrator)
+	if !ok {
+		return errors.New("wallclock: backend does not support MigrateTo")
+	}
+
+	return vm.MigrateTo(ctx, version)
+}
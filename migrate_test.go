@@ -0,0 +1,29 @@
+//
+// Test suite for migrate.go
+//
+
+package tsn
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+
+	wc, err := GetWallClock("rainer")
+
+	if err != nil {
+		fmt.Printf("PANIC %#v\n", err)
+		t.FailNow()
+	}
+
+	err = wc.Migrate(context.Background())
+	if err != nil {
+		fmt.Printf("PANIC %#v\n", err)
+		t.FailNow()
+	}
+
+	fmt.Printf("MIGRATED %v\n", wc.name)
+}
@@ -0,0 +1,244 @@
+// Package migrations bootstraps and evolves the `clock` schema used by
+// the tsn package.
+//
+// Migrations are plain versioned SQL files embedded into the binary via
+// embed.FS, named "NNN_name.up.sql" / "NNN_name.down.sql". Applied versions
+// are tracked in clock.schema_migrations(version, applied_at); a Postgres
+// advisory lock serializes concurrent Migrate/MigrateTo calls so multiple
+// processes booting at once don't race each other running the same file
+// twice.
+//
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed sql/*.sql
+var sqlFS embed.FS
+
+// advisory lock key guarding migration runs for this package. Arbitrary,
+// just needs to be stable and unlikely to collide with other users of
+// pg_advisory_lock in the same database.
+const lockKey int64 = 0x5453_4e00 // "TSN\0"
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// read and pair up the embedded .up.sql/.down.sql files, sorted by version
+func loadMigrations() ([]migration, error) {
+
+	entries, err := sqlFS.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+
+	for _, e := range entries {
+		name := e.Name()
+
+		isUp := strings.HasSuffix(name, ".up.sql")
+		isDown := strings.HasSuffix(name, ".down.sql")
+		if !isUp && !isDown {
+			continue
+		}
+
+		rest := strings.TrimSuffix(strings.TrimSuffix(name, ".up.sql"), ".down.sql")
+		parts := strings.SplitN(rest, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("migrations: bad file name %q: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version}
+			if len(parts) > 1 {
+				m.name = parts[1]
+			}
+			byVersion[version] = m
+		}
+
+		content, err := sqlFS.ReadFile("sql/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		if isUp {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	list := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+
+	return list, nil
+}
+
+// Migrator applies migrations against a single database connection pool.
+type Migrator struct {
+	db *sql.DB
+}
+
+// New builds a Migrator for db. db is not owned by the Migrator - the
+// caller remains responsible for closing it.
+func New(db *sql.DB) *Migrator {
+	return &Migrator{db: db}
+}
+
+func (m *Migrator) ensureTrackingTable(ctx context.Context, tx *sql.Tx) error {
+
+	if _, err := tx.ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS clock`); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS clock.schema_migrations (
+			version    int primary key,
+			applied_at timestamptz not null default now()
+		)
+	`)
+	return err
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, tx *sql.Tx) (map[int]bool, error) {
+
+	rows, err := tx.QueryContext(ctx, `SELECT version FROM clock.schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// Migrate brings the schema up to the latest embedded migration.
+func (m *Migrator) Migrate(ctx context.Context) error {
+
+	migs, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	if len(migs) == 0 {
+		return nil
+	}
+
+	return m.migrateTo(ctx, migs, migs[len(migs)-1].version)
+}
+
+// MigrateTo brings the schema to exactly the given version, running ups
+// or downs as needed.
+func (m *Migrator) MigrateTo(ctx context.Context, version int) error {
+
+	migs, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return m.migrateTo(ctx, migs, version)
+}
+
+func (m *Migrator) migrateTo(ctx context.Context, migs []migration, target int) (err error) {
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = m.ensureTrackingTable(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	applied, err := m.appliedVersions(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	if target >= 0 {
+		for _, mig := range migs {
+			if mig.version > target || applied[mig.version] {
+				continue
+			}
+			if err = m.runStep(ctx, conn, mig.version, mig.up, true); err != nil {
+				return fmt.Errorf("migrations: applying %03d up: %w", mig.version, err)
+			}
+		}
+	}
+
+	for i := len(migs) - 1; i >= 0; i-- {
+		mig := migs[i]
+		if mig.version <= target || !applied[mig.version] {
+			continue
+		}
+		if err = m.runStep(ctx, conn, mig.version, mig.down, false); err != nil {
+			return fmt.Errorf("migrations: reverting %03d down: %w", mig.version, err)
+		}
+	}
+
+	return nil
+}
+
+// run a single migration file and record (or remove) its tracking row in
+// one transaction
+func (m *Migrator) runStep(ctx context.Context, conn *sql.Conn, version int, stmt string, up bool) error {
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO clock.schema_migrations (version) VALUES ($1)`, version)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM clock.schema_migrations WHERE version = $1`, version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+
+	migs, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	if len(migs) != 4 {
+		t.Fatalf("expected 4 migrations, got %v", len(migs))
+	}
+
+	for _, m := range migs {
+		if m.up == "" {
+			t.Fatalf("migration %03d missing up.sql", m.version)
+		}
+		if m.down == "" {
+			t.Fatalf("migration %03d missing down.sql", m.version)
+		}
+	}
+
+	for i, want := range []int{1, 2, 3, 4} {
+		if migs[i].version != want {
+			t.Fatalf("unexpected ordering: %+v", migs)
+		}
+	}
+}
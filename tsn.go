@@ -4,39 +4,46 @@
 //
 // The package offers a function to obtain a monotonically increasing "timestamp" from a "wall clock"
 //
-// Implementation is a PostgreSQL database servicing as the host of the wall clock.
-// The PostgreSQL implementation is using a SEQUENCE there to be bound to a database instance there.
-// The database name is used as the identifier of the wallclock
-// The related database SEQUENCE object is implemented within the "clock" schema of the database
+// Storage is pluggable: a WallClock delegates to a github.com/rainerbrendle/TSN/backends.Backend,
+// selected by the scheme of its DSN (postgres://, sqlite://, mysql://, mem://). The original
+// PostgreSQL implementation lives in backends/postgres and is still the reference one - it uses a
+// SEQUENCE bound to a database instance, exposed via a "clock" schema, with the database name used
+// as the identifier of the wallclock.
+//
+// Backend packages register themselves via backends.Register, the same pattern database/sql uses
+// for drivers - importing tsn alone does not pull in any particular backend; blank-import the one(s)
+// you need (e.g. `_ "github.com/rainerbrendle/TSN/backends/postgres"`).
+//
+// The "clock" schema of the postgres backend is bootstrapped and evolved via the embedded
+// migrations in github.com/rainerbrendle/TSN/migrations, see Migrate/MigrateTo.
 //
 package tsn
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
-	_ "github.com/lib/pq"
 	"os"
 	"strings"
 	"sync"
+
+	"github.com/rainerbrendle/TSN/backends"
 )
 
 // WallClock
 //
 // The object representing a Wallclock with a given name. There is only one Wallclock per one name.
 //
-// Technically this object serves as an anchor point to the database connection
+// Technically this object serves as an anchor point to the storage backend
 // to be managed in a map with the clock "name" as a key
 // Maintained in an internal map structure.
 //
-// to be checked, do we need to keep the database connection or is it good enough to just do the name
-// mapping. Some performance considerations and test about the behaviour of the sql.DB object may be needed
-//
 //
 type WallClock struct {
-	db     *sql.DB // database connection (from database/sql, is pooled)
-	name   string
-	dbname string
+	backend backends.Backend // storage backend, see backends.Open
+	name    string
+	dbname  string
+	batch   batchState // client-side cache used by the NewTSN() fast path, see batch.go
 }
 
 // the global list of wallclocks in the process
@@ -69,6 +76,8 @@ func getDbTemplate() string {
 // translate clock name into db connection string
 // conncetion string needs to have a $database$ variable to be replaced by the clock name
 //
+// The resulting string is a URL-style DSN (postgres://, sqlite://, mysql://, mem://) whose scheme
+// selects the backend, see backends.Open.
 func dbname(name string) string {
 	dn := strings.Replace(getDbTemplate(), "$database$", name, 1)
 
@@ -103,52 +112,18 @@ func checkErr(trace string, err error) {
 
 }
 
-// helper function for tracing a SQL return row
-// some better idea needed eventually (->tracing)
-func checkRow(row *sql.Row) {
-
-	// fmt.Printf( "ROW: %#v\n", row )
-
-}
-
-// Test database connections
-//
-// Initial test for live database connection`
-func ping(db *sql.DB) {
-	var err error
-
-	err = db.Ping()
-
-	checkErr("ping", err)
-}
-
-// Main function (internal)
-//
-// Retrieve a new TSN from database as int64
-func newTSN(db *sql.DB) int64 {
-
-	var tsn int64
-
-	row := db.QueryRow("select clock.new_tsn()")
-	checkRow(row)
-
-	err := row.Scan(&tsn)
-	checkErr("newTSN", err)
-
-	return tsn
-}
-
-// Add a new database connection
+// Add a new wallclock, opening its backend from its DSN
 func add(name string) *WallClock {
 
 	wc := new(WallClock)
 
 	wc.name = name
 	wc.dbname = dbname(name)
-	db, err := sql.Open("postgres", wc.dbname)
+
+	backend, err := backends.Open(wc.dbname)
 	checkErr("add", err)
 
-	wc.db = db
+	wc.backend = backend
 
 	// lock for writing
 	wallclocksRWLock.Lock()
@@ -156,7 +131,12 @@ func add(name string) *WallClock {
 	wallclocksRWLock.Unlock()
 
 	// ping
-	ping(db)
+	checkErr("ping", wc.backend.Ping(context.Background()))
+
+	if DefaultConfig.AutoMigrate {
+		err = wc.Migrate(context.Background())
+		checkErr("auto-migrate", err)
+	}
 
 	return wc
 }
@@ -183,6 +163,12 @@ func GetWallClock(name string) (wc *WallClock, err error) {
 
 // From a given wallclock object retrieve the next TSN
 //
+// Uses the batched/cached fast path (see batch.go): TSNs are handed out of
+// an in-memory buffer that is refilled in bulk via the backend's
+// NewTSNRange, so most calls never touch the backend at all. If that is
+// found to fail the buffer is discarded so a fresh range is fetched once
+// the backend recovers.
+//
 // Package Export
 func (wc *WallClock) NewTSN() (tsn int64, err error) {
 
@@ -196,6 +182,11 @@ func (wc *WallClock) NewTSN() (tsn int64, err error) {
 
 	}()
 
-	tsn = newTSN(wc.db)
+	tsn, err = wc.newTSNCached()
+	if err != nil {
+		wc.discardBatch()
+		checkErr("NewTSN", err)
+	}
+
 	return
 }
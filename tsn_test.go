@@ -7,6 +7,10 @@ package tsn
 import (
 	"fmt"
 	"testing"
+
+	// tsn itself doesn't import any concrete backend (see backends.Register) -
+	// the test binary needs one registered to exercise GetWallClock against.
+	_ "github.com/rainerbrendle/TSN/backends/postgres"
 )
 
 func TestNewTSN(t *testing.T) {